@@ -8,19 +8,36 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/lib/pq"
 	"golang.org/x/discovery/internal"
 	"golang.org/x/discovery/internal/derrors"
 	"golang.org/x/discovery/internal/proxy"
+	"golang.org/x/mod/semver"
 	"golang.org/x/xerrors"
 )
 
 // GetDirectory returns the directory corresponding to the specified dirPath
 // version. The directory will contain all packages for that version, in sorted
 // order by package path. If version is empty, the directory corresponding to
-// the latest matching module version will be fetched.
+// the latest matching module version will be fetched; versions retracted by
+// their module author are never selected as the latest.
+//
+// version also accepts the query forms supported by proxy.Client.Query: a
+// bare vN or vN.M selects the highest matching release, and a comparison
+// predicate (<v, <=v, >v, >=v) selects the highest release satisfying it; in
+// both cases, if no release matches, the highest matching prerelease is
+// selected.
+// The bare "patch" and "upgrade" queries are also accepted; since there is
+// no caller-supplied current version to stay above (unlike
+// proxy.Client.Query's current parameter), they resolve against the
+// module's own highest non-retracted release instead. To resolve patch or
+// upgrade relative to an explicit current version, pass "<current>+patch"
+// or "<current>+upgrade".
 //
 // Packages will be returned for a given dirPath if:
 // (1) the package path has a prefix of dirPath+"/"
@@ -42,6 +59,18 @@ import (
 func (db *DB) GetDirectory(ctx context.Context, dirPath, version string) (_ *internal.Directory, err error) {
 	defer derrors.Wrap(&err, "DB.GetDirectory(ctx, %q, %q)", dirPath, version)
 
+	if current, wantPatch, ok := parsePatchOrUpgrade(version); ok {
+		version, err = db.resolvePatchOrUpgrade(ctx, dirPath, current, wantPatch)
+		if err != nil {
+			return nil, err
+		}
+	} else if version == "patch" || version == "upgrade" {
+		version, err = db.resolvePatchOrUpgrade(ctx, dirPath, "", version == "patch")
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	query, args := constructDirectoryQueryAndArgs(dirPath, version)
 
 	var packages []*internal.VersionedPackage
@@ -108,7 +137,77 @@ func constructDirectoryQueryAndArgs(dirPath, version string) (string, []interfac
 		FROM
 			packages p`
 
-	if version != proxy.Latest {
+	switch {
+	case version == proxy.Latest || version == "":
+		return baseQuery + `
+			INNER JOIN (
+				SELECT
+					DISTINCT ON (module_path) module_path,
+					version,
+					readme_file_path,
+					readme_contents,
+					commit_time,
+					version_type,
+					repository_url,
+					vcs_type,
+					homepage_url
+				FROM
+					versions
+				WHERE
+					$1 || '/' LIKE module_path || '/' || '%'
+					AND NOT retracted
+				ORDER BY
+					-- Order the versions by release then prerelease.
+					-- The default version should be the first release
+					-- version available, if one exists.
+					module_path,
+					CASE WHEN prerelease = '~' THEN 0 ELSE 1 END,
+					major DESC,
+					minor DESC,
+					patch DESC,
+					prerelease DESC
+			) v
+			ON
+				v.module_path = p.module_path
+				AND v.version = p.version
+			WHERE
+				path LIKE $1 || '/' || '%';`, []interface{}{dirPath}
+
+	case isVersionQuery(version):
+		cond, condArgs := versionQueryCondition(version, 2)
+		return baseQuery + `
+			INNER JOIN (
+				SELECT
+					DISTINCT ON (module_path) module_path,
+					version,
+					readme_file_path,
+					readme_contents,
+					commit_time,
+					version_type,
+					repository_url,
+					vcs_type,
+					homepage_url
+				FROM
+					versions
+				WHERE
+					$1 || '/' LIKE module_path || '/' || '%'
+					AND NOT retracted
+					AND ` + cond + `
+				ORDER BY
+					module_path,
+					CASE WHEN prerelease = '~' THEN 0 ELSE 1 END,
+					major DESC,
+					minor DESC,
+					patch DESC,
+					prerelease DESC
+			) v
+			ON
+				v.module_path = p.module_path
+				AND v.version = p.version
+			WHERE
+				path LIKE $1 || '/' || '%';`, append([]interface{}{dirPath}, condArgs...)
+
+	default:
 		return baseQuery + `
 			INNER JOIN (
 				SELECT *
@@ -123,37 +222,119 @@ func constructDirectoryQueryAndArgs(dirPath, version string) (string, []interfac
 			WHERE
 				path LIKE $1 || '/%';`, []interface{}{dirPath, version}
 	}
+}
+
+// isVersionQuery reports whether version is a bare major (vN), a
+// major.minor (vN.M), or a comparison predicate (<v, <=v, >v, >=v), as
+// opposed to an exact version.
+func isVersionQuery(version string) bool {
+	return bareMajorRE.MatchString(version) || majorMinorRE.MatchString(version) ||
+		proxy.IsComparison(version)
+}
+
+var (
+	bareMajorRE  = regexp.MustCompile(`^v[0-9]+$`)
+	majorMinorRE = regexp.MustCompile(`^v[0-9]+\.[0-9]+$`)
+)
+
+// versionQueryCondition translates a version query (as matched by
+// isVersionQuery) into a SQL boolean expression over the versions table's
+// major, minor, patch and prerelease columns, along with the arguments it
+// references, numbered starting at firstArg.
+func versionQueryCondition(version string, firstArg int) (string, []interface{}) {
+	switch {
+	case bareMajorRE.MatchString(version):
+		major, _ := strconv.Atoi(version[1:])
+		return fmt.Sprintf("major = $%d", firstArg), []interface{}{major}
+
+	case majorMinorRE.MatchString(version):
+		parts := strings.SplitN(version[1:], ".", 2)
+		major, _ := strconv.Atoi(parts[0])
+		minor, _ := strconv.Atoi(parts[1])
+		return fmt.Sprintf("major = $%d AND minor = $%d", firstArg, firstArg+1), []interface{}{major, minor}
+
+	default:
+		// Only isVersionQuery's comparison-predicate case reaches here, so
+		// version is safe to split with proxy.SplitComparison.
+		op, v := proxy.SplitComparison(version)
+		major, minor, patch, prerelease := semverParts(v)
+		return fmt.Sprintf("(major, minor, patch, prerelease) %s ($%d, $%d, $%d, $%d)", op, firstArg, firstArg+1, firstArg+2, firstArg+3),
+			[]interface{}{major, minor, patch, prerelease}
+	}
+}
 
-	return baseQuery + `
-		INNER JOIN (
-			SELECT
-				DISTINCT ON (module_path) module_path,
-				version,
-				readme_file_path,
-				readme_contents,
-				commit_time,
-				version_type,
-				repository_url,
-				vcs_type,
-				homepage_url
-			FROM
-				versions
-			WHERE
-				$1 || '/' LIKE module_path || '/' || '%'
-			ORDER BY
-				-- Order the versions by release then prerelease.
-				-- The default version should be the first release
-				-- version available, if one exists.
-				module_path,
-				CASE WHEN prerelease = '~' THEN 0 ELSE 1 END,
-				major DESC,
-				minor DESC,
-				patch DESC,
-				prerelease DESC
-		) v
-		ON
-			v.module_path = p.module_path
-			AND v.version = p.version
+// semverParts breaks a semver string into the components stored by the
+// versions table, using "~" for prerelease to match the sentinel the table
+// uses for non-prerelease versions.
+func semverParts(v string) (major, minor, patch int, prerelease string) {
+	major, _ = strconv.Atoi(strings.TrimPrefix(semver.Major(v), "v"))
+	mm := strings.TrimPrefix(semver.MajorMinor(v), semver.Major(v)+".")
+	minor, _ = strconv.Atoi(mm)
+	core := strings.TrimSuffix(strings.TrimPrefix(semver.Canonical(v), semver.MajorMinor(v)+"."), semver.Prerelease(v))
+	core = strings.TrimSuffix(core, "-")
+	patch, _ = strconv.Atoi(core)
+	prerelease = strings.TrimPrefix(semver.Prerelease(v), "-")
+	if prerelease == "" {
+		prerelease = "~"
+	}
+	return major, minor, patch, prerelease
+}
+
+// parsePatchOrUpgrade recognizes the "<current>+patch" and
+// "<current>+upgrade" forms of version, returning the current version and
+// which of the two queries was requested.
+func parsePatchOrUpgrade(version string) (current string, wantPatch bool, ok bool) {
+	switch {
+	case strings.HasSuffix(version, "+patch"):
+		return strings.TrimSuffix(version, "+patch"), true, true
+	case strings.HasSuffix(version, "+upgrade"):
+		return strings.TrimSuffix(version, "+upgrade"), false, true
+	default:
+		return "", false, false
+	}
+}
+
+// resolvePatchOrUpgrade resolves a patch or upgrade query against the
+// tagged versions of dirPath's module, returning the version that
+// constructDirectoryQueryAndArgs should fetch. current may be empty (for a
+// bare "patch" or "upgrade" query with no version to stay above), in which
+// case patch's major.minor constraint is dropped and both queries simply
+// return the module's highest non-retracted release.
+
+func (db *DB) resolvePatchOrUpgrade(ctx context.Context, dirPath, current string, wantPatch bool) (_ string, err error) {
+	defer derrors.Wrap(&err, "resolvePatchOrUpgrade(ctx, %q, %q, %t)", dirPath, current, wantPatch)
+
+	query := `
+		SELECT version
+		FROM versions
 		WHERE
-			path LIKE $1 || '/' || '%';`, []interface{}{dirPath}
+			$1 || '/' LIKE module_path || '/' || '%'
+			AND NOT retracted
+			AND prerelease = '~'`
+	args := []interface{}{dirPath}
+	if wantPatch && current != "" {
+		query += ` AND major = $2 AND minor = $3`
+		major, minor, _, _ := semverParts(current)
+		args = append(args, major, minor)
+	}
+	query += `
+		ORDER BY major DESC, minor DESC, patch DESC
+		LIMIT 1;`
+
+	var highest string
+	collect := func(rows *sql.Rows) error { return rows.Scan(&highest) }
+	if err := db.runQuery(ctx, query, collect, args...); err != nil {
+		return "", err
+	}
+	if highest == "" {
+		// No matching release; fall back to the version the caller already
+		// has, or (for a bare patch/upgrade query, where there is none) to
+		// GetDirectory's own empty-version latest handling.
+		return current, nil
+	}
+	if !wantPatch && current != "" && semver.Compare(highest, current) < 0 {
+		// upgrade never returns a version older than current.
+		return current, nil
+	}
+	return highest, nil
 }
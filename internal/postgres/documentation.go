@@ -0,0 +1,99 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/safehtml/legacyconversions"
+	"golang.org/x/discovery/internal"
+	"golang.org/x/discovery/internal/derrors"
+)
+
+// GetDocumentation returns the rendered documentation for packagePath at
+// modulePath@version, one entry per (GOOS, GOARCH) build the worker
+// rendered for it, ordered by GOOS then GOARCH.
+func (db *DB) GetDocumentation(ctx context.Context, modulePath, version, packagePath string) (_ []*internal.Documentation, err error) {
+	defer derrors.Wrap(&err, "DB.GetDocumentation(ctx, %q, %q, %q)", modulePath, version, packagePath)
+
+	query := `
+		SELECT
+			goos,
+			goarch,
+			synopsis,
+			html
+		FROM
+			documentation
+		WHERE
+			module_path = $1
+			AND version = $2
+			AND package_path = $3
+		ORDER BY
+			goos,
+			goarch;`
+
+	var docs []*internal.Documentation
+	collect := func(rows *sql.Rows) error {
+		var d internal.Documentation
+		var html string
+		if err := rows.Scan(&d.GOOS, &d.GOARCH, &d.Synopsis, &html); err != nil {
+			return err
+		}
+		d.HTML = legacyconversions.RiskilyAssumeHTML(html)
+		docs = append(docs, &d)
+		return nil
+	}
+	if err := db.runQuery(ctx, query, collect, modulePath, version, packagePath); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// GetUnitDocumentation returns the Unit for packagePath at
+// modulePath@version, populated with its per-(GOOS, GOARCH) documentation
+// so that callers can use Unit.DocumentationFor to pick a build.
+//
+// It only fills in the fields this package and GetDocumentation can supply
+// (PathInfo's Path, ModulePath and Version, and Documentation); the other
+// PathInfo fields are left zero. Wiring it in behind an actual unit-fetch
+// path (readme, licenses, imports) and behind the frontend's
+// ?GOOS=&GOARCH= query parameter belongs to those packages, not here.
+func (db *DB) GetUnitDocumentation(ctx context.Context, modulePath, version, packagePath string) (_ *internal.Unit, err error) {
+	defer derrors.Wrap(&err, "DB.GetUnitDocumentation(ctx, %q, %q, %q)", modulePath, version, packagePath)
+
+	docs, err := db.GetDocumentation(ctx, modulePath, version, packagePath)
+	if err != nil {
+		return nil, err
+	}
+	return &internal.Unit{
+		PathInfo: internal.PathInfo{
+			Path:       packagePath,
+			ModulePath: modulePath,
+			Version:    version,
+		},
+		Documentation: docs,
+	}, nil
+}
+
+// GetDirectoryDocumentation calls GetUnitDocumentation for every package in
+// dir (as returned by GetDirectory), keyed by package path, so a caller
+// rendering a directory listing can look up each package's per-(GOOS,
+// GOARCH) documentation without threading GetDirectory's internal
+// constructDirectoryQueryAndArgs query through the documentation table
+// itself.
+func (db *DB) GetDirectoryDocumentation(ctx context.Context, dir *internal.Directory) (_ map[string][]*internal.Documentation, err error) {
+	defer derrors.Wrap(&err, "DB.GetDirectoryDocumentation(ctx, dir(%q, %q))", dir.Path, dir.Version)
+
+	docs := make(map[string][]*internal.Documentation, len(dir.Packages))
+	for _, pkg := range dir.Packages {
+		u, err := db.GetUnitDocumentation(ctx, pkg.ModulePath, pkg.Version, pkg.Path)
+		if err != nil {
+			return nil, err
+		}
+		docs[pkg.Path] = u.Documentation
+	}
+	return docs, nil
+}
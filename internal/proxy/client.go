@@ -0,0 +1,640 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package proxy provides a client for communicating with a module proxy.
+package proxy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/discovery/internal/derrors"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/xerrors"
+)
+
+// Latest signifies the latest available version in GetInfo requests.
+const Latest = "latest"
+
+// ErrNoMatchingVersion indicates that a version query did not resolve to
+// any version of the requested module. It is distinct from
+// derrors.NotFound, which indicates that the module itself is unknown to
+// the proxy.
+var ErrNoMatchingVersion = xerrors.New("proxy: no version matches query")
+
+// Client is a client for communicating with a module proxy.
+type Client struct {
+	// url is the proxy URL, without a trailing slash.
+	url        string
+	httpClient *http.Client
+}
+
+// New constructs a *Client, with the given base URL for the module proxy.
+func New(url string) *Client {
+	return &Client{
+		url:        strings.TrimRight(url, "/"),
+		httpClient: &http.Client{Timeout: 1 * time.Minute},
+	}
+}
+
+// VersionInfo holds the version and commit time returned by the proxy's
+// @v/<version>.info endpoint.
+type VersionInfo struct {
+	Version string
+	Time    time.Time
+}
+
+// GetInfo returns version information for modulePath and query. It calls
+// Query with an empty current version, so query may take any of the forms
+// Query accepts; without a current version, upgrade resolves like latest and
+// patch fails with ErrNoMatchingVersion. Use Query to resolve upgrade or
+// patch relative to a current version.
+func (c *Client) GetInfo(ctx context.Context, modulePath, query string) (_ *VersionInfo, err error) {
+	defer derrors.Wrap(&err, "GetInfo(ctx, %q, %q)", modulePath, query)
+	return c.Query(ctx, modulePath, query, "")
+}
+
+// Query resolves query, a version query in the grammar supported by cmd/go,
+// against the tagged versions of modulePath, and returns version
+// information for the version it resolves to.
+//
+// The supported forms of query are:
+//
+//	latest             the highest tagged release, or (if the module has no
+//	                   releases) the highest tagged prerelease
+//	upgrade            like latest, but never returns a version lower than current
+//	patch              the highest release sharing current's major and minor version
+//	vN                 the highest vN.x.x release
+//	vN.M               the highest vN.M.x release
+//	<v, <=v, >v, >=v   the highest release satisfying the comparison, or (if no
+//	                   release does) the highest prerelease satisfying it
+//	anything else      a literal commit, branch or tag, which the proxy
+//	                   resolves (and, if necessary, turns into a pseudo-version)
+//
+// current is the module's currently-required version; it is only consulted
+// for the upgrade and patch queries, and may be empty otherwise.
+func (c *Client) Query(ctx context.Context, modulePath, query, current string) (_ *VersionInfo, err error) {
+	defer derrors.Wrap(&err, "Query(ctx, %q, %q, %q)", modulePath, query, current)
+
+	resolved, needsFetch, err := c.resolveQuery(ctx, modulePath, query, current)
+	if err != nil {
+		return nil, err
+	}
+	if !needsFetch {
+		// The upgrade and patch queries fall back to returning current
+		// verbatim when nothing tagged is newer (for patch, nothing tagged
+		// in current's major.minor).
+		// current isn't necessarily a version the proxy knows about, so
+		// don't round-trip it through @v/<version>.info.
+		return &VersionInfo{Version: resolved}, nil
+	}
+	return c.fetchInfo(ctx, modulePath, resolved)
+}
+
+// resolveQuery turns query into a concrete version or ref that can be
+// passed to the @v/<version>.info endpoint. needsFetch reports whether the
+// resolved version actually needs to be looked up that way: it is false
+// only when resolution fell back to returning current as-is, since current
+// may not be a version the proxy can resolve.
+func (c *Client) resolveQuery(ctx context.Context, modulePath, query, current string) (resolved string, needsFetch bool, err error) {
+	switch {
+	case query == "", query == Latest, query == "upgrade", query == "patch",
+		isBareMajor(query), isMajorMinor(query), isComparison(query):
+		versions, err := c.ListVersions(ctx, modulePath)
+		if err != nil {
+			return "", false, err
+		}
+		versions, err = c.dropRetracted(ctx, modulePath, versions)
+		if err != nil {
+			return "", false, err
+		}
+		resolved, err := resolveFromVersions(versions, query, current)
+		if err != nil {
+			return "", false, err
+		}
+		return resolved, containsVersion(versions, resolved), nil
+	default:
+		// A literal commit, branch or tag: let the proxy resolve it.
+		return query, true, nil
+	}
+}
+
+// containsVersion reports whether v is among versions.
+func containsVersion(versions []string, v string) bool {
+	for _, x := range versions {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveFromVersions picks the version satisfying query out of versions,
+// a list of tagged semver versions for a module.
+func resolveFromVersions(versions []string, query, current string) (string, error) {
+	var releases, prereleases []string
+	for _, v := range versions {
+		if !semver.IsValid(v) {
+			continue
+		}
+		if semver.Prerelease(v) == "" {
+			releases = append(releases, v)
+		} else {
+			prereleases = append(prereleases, v)
+		}
+	}
+	sortDescending(releases)
+	sortDescending(prereleases)
+
+	switch {
+	case query == "" || query == Latest:
+		if len(releases) > 0 {
+			return releases[0], nil
+		}
+		if len(prereleases) > 0 {
+			return prereleases[0], nil
+		}
+		return "", xerrors.Errorf("%w: no tagged versions", ErrNoMatchingVersion)
+
+	case query == "upgrade":
+		v, err := resolveFromVersions(append(append([]string{}, releases...), prereleases...), Latest, "")
+		if err != nil {
+			return "", err
+		}
+		if current != "" && semver.Compare(current, v) > 0 {
+			return current, nil
+		}
+		return v, nil
+
+	case query == "patch":
+		if current == "" {
+			return "", xerrors.Errorf("%w: patch query requires a current version", ErrNoMatchingVersion)
+		}
+		prefix := semver.MajorMinor(current) + "."
+		for _, v := range releases {
+			if strings.HasPrefix(v, prefix) {
+				if semver.Compare(v, current) > 0 {
+					return v, nil
+				}
+				return current, nil
+			}
+		}
+		return current, nil
+
+	case isBareMajor(query):
+		return highestWithPrefix(releases, query+".")
+
+	case isMajorMinor(query):
+		return highestWithPrefix(releases, query+".")
+
+	case isComparison(query):
+		op, v := SplitComparison(query)
+		for _, r := range releases {
+			if compareSatisfies(r, op, v) {
+				return r, nil
+			}
+		}
+		for _, p := range prereleases {
+			if compareSatisfies(p, op, v) {
+				return p, nil
+			}
+		}
+		return "", xerrors.Errorf("%w: no version %s %s", ErrNoMatchingVersion, op, v)
+	}
+	return "", xerrors.Errorf("%w: unrecognized query %q", ErrNoMatchingVersion, query)
+}
+
+func highestWithPrefix(releases []string, prefix string) (string, error) {
+	for _, v := range releases {
+		if strings.HasPrefix(v, prefix) {
+			return v, nil
+		}
+	}
+	return "", xerrors.Errorf("%w: no release matching %s*", ErrNoMatchingVersion, prefix)
+}
+
+func compareSatisfies(v, op, want string) bool {
+	c := semver.Compare(v, want)
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+func sortDescending(vs []string) {
+	// insertion sort is fine: proxy version lists are small
+	for i := 1; i < len(vs); i++ {
+		for j := i; j > 0 && semver.Compare(vs[j], vs[j-1]) > 0; j-- {
+			vs[j], vs[j-1] = vs[j-1], vs[j]
+		}
+	}
+}
+
+func isBareMajor(q string) bool {
+	return len(q) > 1 && q[0] == 'v' && !strings.ContainsAny(q, ".-+") && isDigits(q[1:])
+}
+
+func isMajorMinor(q string) bool {
+	if len(q) < 2 || q[0] != 'v' {
+		return false
+	}
+	parts := strings.SplitN(q[1:], ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	return isDigits(parts[0]) && isDigits(parts[1])
+}
+
+func isComparison(q string) bool {
+	return IsComparison(q)
+}
+
+// IsComparison reports whether q is a comparison predicate (<v, <=v, >v,
+// >=v) in the version-query grammar cmd/go understands. It is exported so
+// other packages that need to recognize or split the same predicates (for
+// example postgres.versionQueryCondition) don't have to re-derive the
+// grammar themselves.
+func IsComparison(q string) bool {
+	// A comparison needs at least an operator and a version, for example
+	// "<v1". A bare "<" or ">" isn't one, and must fall through to being
+	// resolved as a literal commit, branch or tag instead.
+	return len(q) >= 2 && (strings.HasPrefix(q, "<") || strings.HasPrefix(q, ">"))
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchInfo fetches version information for the exact version or ref
+// resolvedVersion, using the proxy's @v/<version>.info endpoint.
+func (c *Client) fetchInfo(ctx context.Context, modulePath, resolvedVersion string) (_ *VersionInfo, err error) {
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return nil, xerrors.Errorf("module.EscapePath(%q): %v: %w", modulePath, err, derrors.InvalidArgument)
+	}
+	escapedVersion, err := module.EscapeVersion(resolvedVersion)
+	if err != nil {
+		return nil, xerrors.Errorf("module.EscapeVersion(%q): %v: %w", resolvedVersion, err, derrors.InvalidArgument)
+	}
+	u := fmt.Sprintf("%s/%s/@v/%s.info", c.url, escapedPath, escapedVersion)
+	body, err := c.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	var v VersionInfo
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal: %v", err)
+	}
+	return &v, nil
+}
+
+// ListVersions returns the list of known tagged versions of modulePath, in
+// the order returned by the proxy's @v/list endpoint.
+func (c *Client) ListVersions(ctx context.Context, modulePath string) (_ []string, err error) {
+	defer derrors.Wrap(&err, "ListVersions(ctx, %q)", modulePath)
+
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return nil, xerrors.Errorf("module.EscapePath(%q): %v: %w", modulePath, err, derrors.InvalidArgument)
+	}
+	u := fmt.Sprintf("%s/%s/@v/list", c.url, escapedPath)
+	body, err := c.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+		if line != "" {
+			versions = append(versions, line)
+		}
+	}
+	return versions, nil
+}
+
+// ListVersionsOptions configures ListVersionsWithOptions.
+type ListVersionsOptions struct {
+	// Constraint, if non-empty, is a comma-separated list of semver
+	// comparison predicates (for example ">=v1.2.0,<v2") that versions
+	// must satisfy to be included in the result.
+	Constraint string
+
+	// IncludePrerelease determines whether prerelease versions are
+	// included in the result. Releases are always included.
+	IncludePrerelease bool
+
+	// Descending sorts the result by semver descending; the default is
+	// ascending.
+	Descending bool
+
+	// Limit caps the number of versions returned. Zero means no limit.
+	Limit int
+
+	// Cursor resumes a previous paginated call: it should be the
+	// NextCursor from a prior ListVersionsResult, and causes the result to
+	// pick up immediately after that page.
+	Cursor string
+}
+
+// ListVersionsResult is the result of a call to ListVersionsWithOptions.
+type ListVersionsResult struct {
+	// Versions is the page of versions satisfying the request.
+	Versions []string
+
+	// NextCursor, if non-empty, can be passed as ListVersionsOptions.Cursor
+	// to fetch the next page.
+	NextCursor string
+}
+
+// ListVersionsWithOptions returns the tagged versions of modulePath that
+// satisfy opts, sorted and paginated as opts directs. Versions are sorted
+// using the same key cmd/go uses: releases before prereleases, then
+// major/minor/patch/prerelease, in the requested direction.
+//
+// This makes a live call to the proxy, so postgres.DB's directory-latest
+// logic (which needs the same constraint/sort/prerelease filtering, but
+// can't afford an HTTP round trip per request) reimplements the filtering
+// directly in SQL against the versions table instead of calling this
+// method; see postgres.versionQueryCondition.
+func (c *Client) ListVersionsWithOptions(ctx context.Context, modulePath string, opts ListVersionsOptions) (_ *ListVersionsResult, err error) {
+	defer derrors.Wrap(&err, "ListVersionsWithOptions(ctx, %q, %+v)", modulePath, opts)
+
+	all, err := c.ListVersions(ctx, modulePath)
+	if err != nil {
+		return nil, err
+	}
+
+	preds, err := parseConstraint(opts.Constraint)
+	if err != nil {
+		return nil, xerrors.Errorf("%v: %w", err, derrors.InvalidArgument)
+	}
+
+	var filtered []string
+	for _, v := range all {
+		if !semver.IsValid(v) {
+			continue
+		}
+		if semver.Prerelease(v) != "" && !opts.IncludePrerelease {
+			continue
+		}
+		if !satisfiesAll(v, preds) {
+			continue
+		}
+		filtered = append(filtered, v)
+	}
+	sortByReleaseThenSemver(filtered, opts.Descending)
+
+	start := 0
+	if opts.Cursor != "" {
+		for i, v := range filtered {
+			if v == opts.Cursor {
+				start = i + 1
+				break
+			}
+		}
+	}
+	filtered = filtered[start:]
+
+	result := &ListVersionsResult{}
+	if opts.Limit > 0 && len(filtered) > opts.Limit {
+		result.Versions = filtered[:opts.Limit]
+		result.NextCursor = result.Versions[len(result.Versions)-1]
+	} else {
+		result.Versions = filtered
+	}
+	return result, nil
+}
+
+// parseConstraint parses a comma-separated list of comparison predicates,
+// such as ">=v1.2.0,<v2".
+func parseConstraint(constraint string) ([]predicate, error) {
+	if constraint == "" {
+		return nil, nil
+	}
+	var preds []predicate
+	for _, clause := range strings.Split(constraint, ",") {
+		clause = strings.TrimSpace(clause)
+		if !isComparison(clause) {
+			return nil, fmt.Errorf("invalid constraint clause %q", clause)
+		}
+		op, v := SplitComparison(clause)
+		v = canonicalizePrefix(v)
+		if !semver.IsValid(v) {
+			return nil, fmt.Errorf("invalid version %q in constraint %q", v, clause)
+		}
+		preds = append(preds, predicate{op: op, version: v})
+	}
+	return preds, nil
+}
+
+// canonicalizePrefix expands a bare vN or vN.M into the lowest version with
+// that prefix (vN.0.0 or vN.M.0), so it can be compared with semver.Compare.
+func canonicalizePrefix(v string) string {
+	switch {
+	case isBareMajor(v):
+		return v + ".0.0"
+	case isMajorMinor(v):
+		return v + ".0"
+	default:
+		return v
+	}
+}
+
+type predicate struct {
+	op      string
+	version string
+}
+
+func satisfiesAll(v string, preds []predicate) bool {
+	for _, p := range preds {
+		if !compareSatisfies(v, p.op, p.version) {
+			return false
+		}
+	}
+	return true
+}
+
+// SplitComparison splits a comparison predicate (as recognized by
+// IsComparison) into its operator and version operand, for example
+// "<=v1.2.0" into "<=" and "v1.2.0". Callers must check IsComparison(clause)
+// first; SplitComparison does not itself validate that clause is long
+// enough to contain an operand.
+func SplitComparison(clause string) (op, version string) {
+	if strings.HasPrefix(clause, "<=") || strings.HasPrefix(clause, ">=") {
+		return clause[:2], clause[2:]
+	}
+	return clause[:1], clause[1:]
+}
+
+// sortByReleaseThenSemver sorts versions the way cmd/go does: releases
+// before prereleases, then by semver, ascending unless descending is set.
+func sortByReleaseThenSemver(versions []string, descending bool) {
+	sort.Slice(versions, func(i, j int) bool {
+		vi, vj := versions[i], versions[j]
+		pi, pj := semver.Prerelease(vi) == "", semver.Prerelease(vj) == ""
+		if pi != pj {
+			return pi // releases sort before prereleases
+		}
+		if descending {
+			return semver.Compare(vi, vj) > 0
+		}
+		return semver.Compare(vi, vj) < 0
+	})
+}
+
+// dropRetracted removes versions that the module author has retracted, as
+// declared by retract directives in the go.mod of the highest available
+// version. A go.mod that can't be fetched or parsed is treated as having
+// no retractions, rather than failing the whole resolution.
+func (c *Client) dropRetracted(ctx context.Context, modulePath string, versions []string) ([]string, error) {
+	if len(versions) == 0 {
+		return versions, nil
+	}
+	retracts, err := c.retractionsFor(ctx, modulePath, highestOverall(versions))
+	if err != nil || len(retracts) == 0 {
+		return versions, nil
+	}
+	var kept []string
+	for _, v := range versions {
+		if retracted, _ := retractionFor(v, retracts); !retracted {
+			kept = append(kept, v)
+		}
+	}
+	return kept, nil
+}
+
+// Retraction reports whether version has been retracted by the module
+// author, and the rationale given for doing so, if any. It consults the
+// retract directives in the go.mod of the highest available version of
+// modulePath.
+func (c *Client) Retraction(ctx context.Context, modulePath, version string) (retracted bool, rationale string, err error) {
+	defer derrors.Wrap(&err, "Retraction(ctx, %q, %q)", modulePath, version)
+
+	versions, err := c.ListVersions(ctx, modulePath)
+	if err != nil {
+		return false, "", err
+	}
+	if len(versions) == 0 {
+		return false, "", nil
+	}
+	retracts, err := c.retractionsFor(ctx, modulePath, highestOverall(versions))
+	if err != nil {
+		return false, "", nil
+	}
+	retracted, rationale = retractionFor(version, retracts)
+	return retracted, rationale, nil
+}
+
+// retractionsFor fetches and parses the go.mod for modulePath at version,
+// returning its retract directives, if any.
+func (c *Client) retractionsFor(ctx context.Context, modulePath, version string) ([]*modfile.Retract, error) {
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return nil, xerrors.Errorf("module.EscapePath(%q): %v: %w", modulePath, err, derrors.InvalidArgument)
+	}
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, xerrors.Errorf("module.EscapeVersion(%q): %v: %w", version, err, derrors.InvalidArgument)
+	}
+	u := fmt.Sprintf("%s/%s/@v/%s.mod", c.url, escapedPath, escapedVersion)
+	body, err := c.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	mf, err := modfile.Parse(u, body, nil)
+	if err != nil {
+		return nil, err
+	}
+	return mf.Retract, nil
+}
+
+// retractionFor reports whether version falls within one of retracts'
+// ranges, and the rationale for the first matching range.
+func retractionFor(version string, retracts []*modfile.Retract) (bool, string) {
+	for _, r := range retracts {
+		if semver.Compare(version, r.Low) >= 0 && semver.Compare(version, r.High) <= 0 {
+			return true, r.Rationale
+		}
+	}
+	return false, ""
+}
+
+// highestOverall returns the semver-highest version in versions.
+func highestOverall(versions []string) string {
+	vs := append([]string{}, versions...)
+	sortDescending(vs)
+	return vs[0]
+}
+
+// GetZip returns the zip file for the given modulePath and version.
+func (c *Client) GetZip(ctx context.Context, modulePath, version string) (_ *zip.Reader, err error) {
+	defer derrors.Wrap(&err, "GetZip(ctx, %q, %q)", modulePath, version)
+
+	escapedPath, err := module.EscapePath(modulePath)
+	if err != nil {
+		return nil, xerrors.Errorf("module.EscapePath(%q): %v: %w", modulePath, err, derrors.InvalidArgument)
+	}
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, xerrors.Errorf("module.EscapeVersion(%q): %v: %w", version, err, derrors.InvalidArgument)
+	}
+	u := fmt.Sprintf("%s/%s/@v/%s.zip", c.url, escapedPath, escapedVersion)
+	body, err := c.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(body), int64(len(body)))
+}
+
+func (c *Client) get(ctx context.Context, u string) (_ []byte, err error) {
+	defer derrors.Wrap(&err, "get(ctx, %q)", u)
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusNotFound, http.StatusGone:
+		return nil, xerrors.Errorf("%q: %w", strings.TrimSpace(string(body)), derrors.NotFound)
+	default:
+		return nil, xerrors.Errorf("unexpected status %s (%s): %q", strconv.Itoa(resp.StatusCode), resp.Status, body)
+	}
+}
@@ -63,6 +63,99 @@ func TestListVersions(t *testing.T) {
 	}
 }
 
+func TestListVersionsWithOptions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	modulePath := "foo.com/bar"
+	testVersions := []*TestVersion{
+		NewTestVersion(t, modulePath, "v1.0.0", map[string]string{"bar.go": "package bar"}),
+		NewTestVersion(t, modulePath, "v1.2.0", map[string]string{"bar.go": "package bar"}),
+		NewTestVersion(t, modulePath, "v1.2.1-beta.1", map[string]string{"bar.go": "package bar"}),
+		NewTestVersion(t, modulePath, "v2.0.0", map[string]string{"bar.go": "package bar"}),
+	}
+	client, teardownProxy := SetupTestProxy(t, testVersions)
+	defer teardownProxy()
+
+	for _, tc := range []struct {
+		name string
+		opts ListVersionsOptions
+		want []string
+	}{
+		{
+			name: "releases only, ascending",
+			opts: ListVersionsOptions{},
+			want: []string{"v1.0.0", "v1.2.0", "v2.0.0"},
+		},
+		{
+			name: "include prerelease",
+			opts: ListVersionsOptions{IncludePrerelease: true},
+			want: []string{"v1.0.0", "v1.2.0", "v2.0.0", "v1.2.1-beta.1"},
+		},
+		{
+			name: "descending",
+			opts: ListVersionsOptions{Descending: true},
+			want: []string{"v2.0.0", "v1.2.0", "v1.0.0"},
+		},
+		{
+			name: "constraint",
+			opts: ListVersionsOptions{Constraint: ">=v1.2.0,<v2"},
+			want: []string{"v1.2.0"},
+		},
+		{
+			name: "limit",
+			opts: ListVersionsOptions{Limit: 2},
+			want: []string{"v1.0.0", "v1.2.0"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := client.ListVersionsWithOptions(ctx, modulePath, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got.Versions); diff != "" {
+				t.Errorf("ListVersionsWithOptions(%+v) diff:\n%s", tc.opts, diff)
+			}
+		})
+	}
+}
+
+func TestListVersionsWithOptionsPagination(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	modulePath := "foo.com/bar"
+	testVersions := []*TestVersion{
+		NewTestVersion(t, modulePath, "v1.0.0", map[string]string{"bar.go": "package bar"}),
+		NewTestVersion(t, modulePath, "v1.1.0", map[string]string{"bar.go": "package bar"}),
+		NewTestVersion(t, modulePath, "v1.2.0", map[string]string{"bar.go": "package bar"}),
+	}
+	client, teardownProxy := SetupTestProxy(t, testVersions)
+	defer teardownProxy()
+
+	page1, err := client.ListVersionsWithOptions(ctx, modulePath, ListVersionsOptions{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"v1.0.0", "v1.1.0"}, page1.Versions); diff != "" {
+		t.Errorf("page1 diff:\n%s", diff)
+	}
+	if page1.NextCursor != "v1.1.0" {
+		t.Errorf("page1.NextCursor = %q, want %q", page1.NextCursor, "v1.1.0")
+	}
+
+	page2, err := client.ListVersionsWithOptions(ctx, modulePath, ListVersionsOptions{Limit: 2, Cursor: page1.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"v1.2.0"}, page2.Versions); diff != "" {
+		t.Errorf("page2 diff:\n%s", diff)
+	}
+	if page2.NextCursor != "" {
+		t.Errorf("page2.NextCursor = %q, want empty", page2.NextCursor)
+	}
+}
+
 func TestGetInfo(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
@@ -153,6 +246,149 @@ func TestGetZip(t *testing.T) {
 	}
 }
 
+func TestQueryGrammar(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	modulePath := "foo.com/bar"
+	testVersions := []*TestVersion{
+		NewTestVersion(t, modulePath, "v1.1.0", map[string]string{"bar.go": "package bar"}),
+		NewTestVersion(t, modulePath, "v1.2.0", map[string]string{"bar.go": "package bar"}),
+		NewTestVersion(t, modulePath, "v1.2.1", map[string]string{"bar.go": "package bar"}),
+		NewTestVersion(t, modulePath, "v2.0.0", map[string]string{"bar.go": "package bar"}),
+	}
+	client, teardownProxy := SetupTestProxy(t, testVersions)
+	defer teardownProxy()
+
+	for _, tc := range []struct {
+		query, current, want string
+	}{
+		{query: Latest, want: "v2.0.0"},
+		{query: "upgrade", current: "v1.1.0", want: "v2.0.0"},
+		{query: "upgrade", current: "v3.0.0", want: "v3.0.0"}, // current is newer than latest
+		{query: "patch", current: "v1.2.0", want: "v1.2.1"},
+		{query: "patch", current: "v1.2.1", want: "v1.2.1"}, // current is already the latest patch
+		{query: "v1", want: "v1.2.1"},
+		{query: "v1.1", want: "v1.1.0"},
+		{query: "<v2.0.0", want: "v1.2.1"},
+		{query: "<=v1.2.0", want: "v1.2.0"},
+		{query: ">v1.2.1", want: "v2.0.0"},
+		{query: ">=v2.0.0", want: "v2.0.0"},
+	} {
+		t.Run(tc.query+"/"+tc.current, func(t *testing.T) {
+			info, err := client.Query(ctx, modulePath, tc.query, tc.current)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Version != tc.want {
+				t.Errorf("Query(ctx, %q, %q, %q) = %q, want %q", modulePath, tc.query, tc.current, info.Version, tc.want)
+			}
+		})
+	}
+}
+
+func TestQueryPrereleaseOnly(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	modulePath := "foo.com/prerelease"
+	testVersions := []*TestVersion{
+		NewTestVersion(t, modulePath, "v1.0.0-alpha.1", map[string]string{"bar.go": "package bar"}),
+		NewTestVersion(t, modulePath, "v1.0.0-alpha.2", map[string]string{"bar.go": "package bar"}),
+	}
+	client, teardownProxy := SetupTestProxy(t, testVersions)
+	defer teardownProxy()
+
+	info, err := client.GetInfo(ctx, modulePath, Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "v1.0.0-alpha.2"; info.Version != want {
+		t.Errorf("GetInfo(ctx, %q, Latest) = %q, want %q", modulePath, info.Version, want)
+	}
+}
+
+func TestQueryNoMatchingVersion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	modulePath := "foo.com/bar"
+	testVersions := []*TestVersion{
+		NewTestVersion(t, modulePath, "v1.1.0", map[string]string{"bar.go": "package bar"}),
+	}
+	client, teardownProxy := SetupTestProxy(t, testVersions)
+	defer teardownProxy()
+
+	if _, err := client.Query(ctx, modulePath, "v2", ""); !xerrors.Is(err, ErrNoMatchingVersion) {
+		t.Errorf("Query(ctx, %q, %q, %q): err = %v, want %v", modulePath, "v2", "", err, ErrNoMatchingVersion)
+	}
+}
+
+func TestQueryBareComparisonOperator(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	modulePath := "foo.com/bar"
+	testVersions := []*TestVersion{
+		NewTestVersion(t, modulePath, "v1.1.0", map[string]string{"bar.go": "package bar"}),
+	}
+	client, teardownProxy := SetupTestProxy(t, testVersions)
+	defer teardownProxy()
+
+	// A bare "<" or ">" has no operand, so it isn't a comparison query and
+	// must be resolved as a literal ref instead of panicking.
+	for _, query := range []string{"<", ">"} {
+		if _, err := client.Query(ctx, modulePath, query, ""); err == nil {
+			t.Errorf("Query(ctx, %q, %q, %q): want error, got nil", modulePath, query, "")
+		}
+	}
+}
+
+func TestGetInfoSkipsRetractedLatest(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	modulePath := "foo.com/bar"
+	testVersions := []*TestVersion{
+		NewTestVersion(t, modulePath, "v1.0.0", map[string]string{"bar.go": "package bar"}),
+		NewTestVersion(t, modulePath, "v1.1.0", map[string]string{
+			"bar.go": "package bar",
+			"go.mod": "module foo.com/bar\n\ngo 1.16\n\n" +
+				"// Published by mistake; contains a data race.\n" +
+				"retract v1.1.0\n",
+		}),
+	}
+	client, teardownProxy := SetupTestProxy(t, testVersions)
+	defer teardownProxy()
+
+	info, err := client.GetInfo(ctx, modulePath, Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "v1.0.0"; info.Version != want {
+		t.Errorf("GetInfo(ctx, %q, Latest) = %q, want %q (v1.1.0 is retracted)", modulePath, info.Version, want)
+	}
+
+	retracted, rationale, err := client.Retraction(ctx, modulePath, "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !retracted {
+		t.Errorf("Retraction(ctx, %q, %q): retracted = false, want true", modulePath, "v1.1.0")
+	}
+	if want := "Published by mistake; contains a data race."; rationale != want {
+		t.Errorf("Retraction(ctx, %q, %q): rationale = %q, want %q", modulePath, "v1.1.0", rationale, want)
+	}
+
+	retracted, _, err = client.Retraction(ctx, modulePath, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retracted {
+		t.Errorf("Retraction(ctx, %q, %q): retracted = true, want false", modulePath, "v1.0.0")
+	}
+}
+
 func TestGetZipNonExist(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
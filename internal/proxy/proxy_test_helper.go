@@ -0,0 +1,151 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestVersion holds the module path, version and file contents for a single
+// version served by the fake proxy set up by SetupTestProxy.
+type TestVersion struct {
+	modulePath string
+	version    string
+	files      map[string]string
+	zip        []byte
+	goMod      []byte
+}
+
+// NewTestVersion creates a TestVersion for modulePath at version, whose zip
+// file contains the given files (paths relative to modulePath@version/).
+func NewTestVersion(t *testing.T, modulePath, version string, files map[string]string) *TestVersion {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, contents := range files {
+		fw, err := zw.Create(fmt.Sprintf("%s@%s/%s", modulePath, version, name))
+		if err != nil {
+			t.Fatalf("zw.Create(%q): %v", name, err)
+		}
+		if _, err := fw.Write([]byte(contents)); err != nil {
+			t.Fatalf("fw.Write(%q): %v", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zw.Close(): %v", err)
+	}
+	goMod := []byte(fmt.Sprintf("module %s\n", modulePath))
+	if contents, ok := files["go.mod"]; ok {
+		goMod = []byte(contents)
+	}
+	return &TestVersion{
+		modulePath: modulePath,
+		version:    version,
+		files:      files,
+		zip:        buf.Bytes(),
+		goMod:      goMod,
+	}
+}
+
+// defaultTestVersions is served whenever SetupTestProxy is called with a nil
+// version list, so that tests that only care about a single well-known
+// module don't need to construct one.
+func defaultTestVersions(t *testing.T) []*TestVersion {
+	t.Helper()
+	return []*TestVersion{
+		NewTestVersion(t, "github.com/my/module", "v1.0.0", map[string]string{
+			"go.mod":         "module github.com/my/module\n\ngo 1.12\n",
+			"LICENSE":        "BSD-3-Clause",
+			"README.md":      "# my/module\n",
+			"foo/foo.go":     "package foo\n\nconst Foo = 1\n",
+			"foo/LICENSE.md": "MIT",
+			"bar/bar.go":     "package bar\n\nconst Bar = 1\n",
+			"bar/LICENSE":    "BSD-3-Clause",
+		}),
+	}
+}
+
+// SetupTestProxy starts an httptest.Server implementing the module proxy
+// protocol for the given versions (or, if versions is nil, a single
+// built-in test module). It returns a Client configured to talk to that
+// server and a function to shut the server down.
+func SetupTestProxy(t *testing.T, versions []*TestVersion) (*Client, func()) {
+	t.Helper()
+	if versions == nil {
+		versions = defaultTestVersions(t)
+	}
+
+	byModule := map[string][]*TestVersion{}
+	for _, v := range versions {
+		byModule[v.modulePath] = append(byModule[v.modulePath], v)
+	}
+
+	mux := http.NewServeMux()
+	for modulePath, vs := range byModule {
+		modulePath, vs := modulePath, vs
+		byVersion := map[string]*TestVersion{}
+		var list []string
+		for _, v := range vs {
+			byVersion[v.version] = v
+			list = append(list, v.version)
+		}
+		sort.Strings(list)
+
+		mux.HandleFunc("/"+modulePath+"/@v/list", func(w http.ResponseWriter, r *http.Request) {
+			for _, v := range list {
+				fmt.Fprintln(w, v)
+			}
+		})
+		mux.HandleFunc("/"+modulePath+"/@v/", func(w http.ResponseWriter, r *http.Request) {
+			suffix := r.URL.Path[len("/"+modulePath+"/@v/"):]
+			switch {
+			case hasSuffix(suffix, ".info"):
+				version := suffix[:len(suffix)-len(".info")]
+				v, ok := byVersion[version]
+				if !ok {
+					http.Error(w, "unknown version", http.StatusNotFound)
+					return
+				}
+				fmt.Fprintf(w, `{"Version": %q, "Time": %q}`, v.version, time.Date(2019, 1, 30, 0, 0, 0, 0, time.UTC).Format(time.RFC3339))
+			case hasSuffix(suffix, ".zip"):
+				version := suffix[:len(suffix)-len(".zip")]
+				v, ok := byVersion[version]
+				if !ok {
+					http.Error(w, "unknown version", http.StatusNotFound)
+					return
+				}
+				w.Write(v.zip)
+			case hasSuffix(suffix, ".mod"):
+				version := suffix[:len(suffix)-len(".mod")]
+				v, ok := byVersion[version]
+				if !ok {
+					http.Error(w, "unknown version", http.StatusNotFound)
+					return
+				}
+				w.Write(v.goMod)
+			default:
+				http.Error(w, "not found", http.StatusNotFound)
+			}
+		})
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown module", http.StatusNotFound)
+	})
+
+	server := httptest.NewServer(mux)
+	client := New(server.URL)
+	return client, server.Close
+}
+
+func hasSuffix(s, suffix string) bool {
+	return len(s) >= len(suffix) && s[len(s)-len(suffix):] == suffix
+}
@@ -27,6 +27,13 @@ type PathInfo struct {
 	ModulePath string
 	CommitTime time.Time
 	SourceInfo *source.Info
+
+	// Retracted reports whether the module author has retracted this
+	// version, via a retract directive in go.mod.
+	Retracted bool
+	// RetractionRationale is the rationale the module author gave for the
+	// retraction, if any. It is only meaningful when Retracted is true.
+	RetractionRationale string
 }
 
 // IsPackage reports whether the path represents a package path.
@@ -48,6 +55,38 @@ type Unit struct {
 	Readme  *Readme
 	Package *Package
 	Imports []string
+
+	// Documentation holds one entry per (GOOS, GOARCH) build that the
+	// worker rendered for this package, so that build-constrained
+	// identifiers (for example, something that only exists under
+	// linux/amd64) can be shown. It is empty for paths that aren't
+	// packages.
+	Documentation []*Documentation
+}
+
+// DefaultGOOS and DefaultGOARCH are the build used when a caller doesn't
+// specify which of a package's Documentation entries to show.
+const (
+	DefaultGOOS   = "linux"
+	DefaultGOARCH = "amd64"
+)
+
+// DocumentationFor returns the Documentation entry for the given GOOS and
+// GOARCH, falling back to DefaultGOOS/DefaultGOARCH if either is empty. It
+// returns nil if u has no matching entry.
+func (u *Unit) DocumentationFor(goos, goarch string) *Documentation {
+	if goos == "" {
+		goos = DefaultGOOS
+	}
+	if goarch == "" {
+		goarch = DefaultGOARCH
+	}
+	for _, d := range u.Documentation {
+		if d.GOOS == goos && d.GOARCH == goarch {
+			return d
+		}
+	}
+	return nil
 }
 
 // Documentation is the rendered documentation for a given package